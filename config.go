@@ -0,0 +1,219 @@
+package simstore
+
+// Config describes the block-partitioning spec driving a Store's
+// permutation engine: F is the total number of signature bits, split into
+// the given BlockBits (which must sum to F, but need not be the same
+// width), together with K, the hamming distance bound the generated
+// permutations must guarantee coverage for.
+//
+// Permutations() and the offsets Shuffle/Unshuffle need are invariant for
+// a given F/BlockBits/K, but not cheap to recompute - Add and Find each
+// need them once per signature. A Config built directly as a struct
+// literal computes them on demand; one built with newConfig (as cfg3 and
+// cfg6 are) has them precomputed once, and every copy of that Config
+// shares the same cached slices.
+type Config struct {
+	F         int
+	BlockBits []int
+	K         int
+
+	perms []Perm
+	offs  []int
+}
+
+// newConfig builds a Config with its permutations and block offsets
+// precomputed, so the Add/Find hot path never has to recompute - and
+// reallocate - them per call.
+func newConfig(f int, blockBits []int, k int) Config {
+	c := Config{F: f, BlockBits: blockBits, K: k}
+	c.offs = c.buildOffsets()
+	c.perms = c.buildPermutations()
+	return c
+}
+
+// Perm is one of a Config's generated permutations: the block order
+// Shuffle/Unshuffle apply, and the mask selecting the resulting table
+// prefix. Bits (and so Mask) can differ between permutations when
+// BlockBits holds blocks of different widths, since each permutation
+// chooses a different subset of blocks as its prefix.
+type Perm struct {
+	// Blocks is the block order passed to Shuffle/Unshuffle; its first
+	// prefixBlocks() entries are the blocks chosen as this permutation's
+	// table prefix.
+	Blocks []int
+	// Bits is the bit width of the prefix, i.e. the sum of the widths of
+	// the chosen blocks.
+	Bits int
+	// Mask selects the top Bits bits of a signature shuffled by Blocks -
+	// the key used to index this permutation's table.
+	Mask uint64
+}
+
+// prefixBlocks is the number of blocks chosen as a table's prefix. By
+// pigeonhole, any hamming distance <= K error touches at most K of the
+// len(BlockBits) blocks, so at least len(BlockBits)-K blocks are always
+// left untouched; trying every way of choosing that many blocks as the
+// prefix (see Permutations) therefore guarantees one of them lands on an
+// untouched set.
+func (c Config) prefixBlocks() int {
+	g := len(c.BlockBits) - c.K
+	if g < 1 {
+		g = 1
+	}
+	return g
+}
+
+// offsets returns, for every block, the bit shift of its least significant
+// bit when the blocks are laid out MSB-first summing to F bits.
+func (c Config) offsets() []int {
+	if c.offs != nil {
+		return c.offs
+	}
+	return c.buildOffsets()
+}
+
+func (c Config) buildOffsets() []int {
+	offsets := make([]int, len(c.BlockBits))
+	shift := c.F
+	for i, w := range c.BlockBits {
+		shift -= w
+		offsets[i] = shift
+	}
+	return offsets
+}
+
+// Permutations returns, for every way of choosing prefixBlocks() of the
+// blocks (in increasing index order), the Perm that moves the chosen
+// blocks to the front (in original relative order) followed by the
+// remaining blocks (also in original relative order), along with the mask
+// that permutation's resulting prefix occupies.
+func (c Config) Permutations() []Perm {
+	if c.perms != nil {
+		return c.perms
+	}
+	return c.buildPermutations()
+}
+
+func (c Config) buildPermutations() []Perm {
+	g := c.prefixBlocks()
+	combos := genBlockPerms(len(c.BlockBits), g)
+
+	perms := make([]Perm, len(combos))
+	for i, blocks := range combos {
+		bits := 0
+		for _, b := range blocks[:g] {
+			bits += c.BlockBits[b]
+		}
+		perms[i] = Perm{
+			Blocks: blocks,
+			Bits:   bits,
+			Mask:   ^uint64(0) << uint(c.F-bits),
+		}
+	}
+	return perms
+}
+
+// Shuffle reorders the blocks of sig according to perm (as returned by
+// Permutations).
+func (c Config) Shuffle(sig uint64, perm []int) uint64 {
+	offsets := c.offsets()
+
+	var out uint64
+	destShift := c.F
+	for _, src := range perm {
+		w := c.BlockBits[src]
+		destShift -= w
+		block := (sig >> uint(offsets[src])) & (1<<uint(w) - 1)
+		out |= block << uint(destShift)
+	}
+	return out
+}
+
+// Unshuffle is the inverse of Shuffle for the same perm.
+func (c Config) Unshuffle(sig uint64, perm []int) uint64 {
+	offsets := c.offsets()
+
+	var out uint64
+	srcShift := c.F
+	for _, src := range perm {
+		w := c.BlockBits[src]
+		srcShift -= w
+		block := (sig >> uint(srcShift)) & (1<<uint(w) - 1)
+		out |= block << uint(offsets[src])
+	}
+	return out
+}
+
+// genBlockPerms generates, for every g-sized subset of the m block indices
+// 0..m-1 (in increasing order), the permutation that places the subset
+// first followed by the remaining blocks.
+func genBlockPerms(m, g int) [][]int {
+	var perms [][]int
+
+	chosen := make([]int, g)
+	var rec func(start, depth int)
+	rec = func(start, depth int) {
+		if depth == g {
+			used := make([]bool, m)
+			perm := make([]int, 0, m)
+			for _, b := range chosen {
+				used[b] = true
+				perm = append(perm, b)
+			}
+			for i := 0; i < m; i++ {
+				if !used[i] {
+					perm = append(perm, i)
+				}
+			}
+			perms = append(perms, perm)
+			return
+		}
+		for i := start; i < m; i++ {
+			chosen[depth] = i
+			rec(i+1, depth+1)
+		}
+	}
+	rec(0, 0)
+
+	return perms
+}
+
+func blocksOf(width, count int) []int {
+	b := make([]int, count)
+	for i := range b {
+		b[i] = width
+	}
+	return b
+}
+
+// cfg3 and cfg6 are the permutation configs backing New3 and New6.
+//
+// cfg3 splits the signature into one 16-bit block and four 12-bit blocks;
+// with K=3 that's prefixBlocks()=2 blocks chosen as each table's prefix,
+// giving a 24- or 28-bit prefix depending on whether the wide block is
+// among those chosen - selective enough that a table's prefix bucket stays
+// small even at hundreds of millions of signatures. cfg6 splits into eight
+// equal 8-bit blocks, of which 2 are chosen as the prefix (K=6), for a
+// uniform 16-bit prefix across all of its 28 tables. Other block layouts -
+// more, smaller blocks for a longer, more selective prefix at the cost of
+// more tables; 128-bit signatures via F:128 - are a matter of defining a
+// different Config.
+var (
+	cfg3 = newConfig(64, []int{16, 12, 12, 12, 12}, 3)
+	cfg6 = newConfig(64, blocksOf(8, 8), 6)
+)
+
+// config returns the permutation config this store was built with.
+func (s *Store[D]) config() Config {
+	return configForK(s.k)
+}
+
+// configForK returns the permutation config for a given hamming distance
+// bound - the one lookup Store.config and persist.go's loaders both need
+// to go from a store's k to its Config.
+func configForK(k int) Config {
+	if k == 6 {
+		return cfg6
+	}
+	return cfg3
+}
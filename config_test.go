@@ -0,0 +1,62 @@
+package simstore
+
+import "testing"
+
+// TestConfigCoverage is a brute-force check of the pigeonhole guarantee
+// Config.Permutations relies on: for every way to flip up to K of a
+// config's blocks, at least one permutation's prefix blocks are left
+// entirely untouched, so its mask still matches between the original and
+// mutated signature.
+func TestConfigCoverage(t *testing.T) {
+	for _, cfg := range []Config{cfg3, cfg6} {
+		g := cfg.prefixBlocks()
+		perms := cfg.Permutations()
+
+		for errBlocks := 1; errBlocks <= cfg.K; errBlocks++ {
+			for _, mutated := range blockSubsets(len(cfg.BlockBits), errBlocks) {
+				covered := false
+				for _, perm := range perms {
+					if blocksDisjoint(perm.Blocks[:g], mutated) {
+						covered = true
+						break
+					}
+				}
+				if !covered {
+					t.Fatalf("F:%d K:%d: mutating blocks %v isn't covered by any permutation", cfg.F, cfg.K, mutated)
+				}
+			}
+		}
+	}
+}
+
+// blocksDisjoint reports whether none of a appears in b.
+func blocksDisjoint(a, b []int) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// blockSubsets returns every way of choosing k of the m block indices
+// 0..m-1.
+func blockSubsets(m, k int) [][]int {
+	var out [][]int
+	chosen := make([]int, k)
+	var rec func(start, depth int)
+	rec = func(start, depth int) {
+		if depth == k {
+			out = append(out, append([]int(nil), chosen...))
+			return
+		}
+		for i := start; i < m; i++ {
+			chosen[depth] = i
+			rec(i+1, depth+1)
+		}
+	}
+	rec(0, 0)
+	return out
+}
@@ -0,0 +1,492 @@
+package simstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	storeMagic   = "SMST"
+	storeVersion = 1
+
+	// storeHeaderSize is the fixed-width header written at the start of a
+	// saved Store: magic(4) + version(4) + entry count(8) + k(4) + doc
+	// codec size(4), padded out to a round 32 bytes. Keeping it a multiple
+	// of 8 keeps the docids table 8-byte aligned, and with a doc codec whose
+	// encoded size is a fixed multiple of 8 (Uint64Codec) every uint64 field
+	// after it too, which lets loadStoreFromBytes view them in place. After
+	// a variable-length docids table (GobCodec) the rhashes tables can be
+	// unaligned, and viewUint64s then copies them instead.
+	storeHeaderSize = 32
+)
+
+var (
+	errBadMagic   = errors.New("simstore: not a simstore file")
+	errBadVersion = errors.New("simstore: unsupported simstore file version")
+)
+
+// DocCodec controls how a Store's document ids are encoded on disk by Save
+// and LoadStore. Size, if > 0, declares the encoded doc id as a fixed
+// number of bytes; LoadStore then decodes the docids table directly off
+// the mapped file in one pass with no per-entry length prefix to read.
+// Size() == 0 means variable length, in which case entries are stored with
+// a length prefix. Uint64Codec covers the common uint64 doc id case;
+// GobCodec is the fallback for arbitrary D.
+type DocCodec[D any] interface {
+	// Encode appends the encoded form of doc to buf and returns the result.
+	Encode(buf []byte, doc D) []byte
+	// Decode decodes a doc from the front of buf, returning the doc and the
+	// number of bytes consumed.
+	Decode(buf []byte) (D, int)
+	// Size returns the fixed encoded width in bytes, or 0 if variable length.
+	Size() int
+}
+
+// Uint64Codec is a DocCodec for uint64 document ids. It's also the only
+// codec Open's zero-copy path supports, since it's the only one whose
+// encoding matches entry[uint64]'s in-memory layout exactly.
+type Uint64Codec struct{}
+
+func (Uint64Codec) Size() int { return 8 }
+
+func (Uint64Codec) Encode(buf []byte, doc uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], doc)
+	return append(buf, b[:]...)
+}
+
+func (Uint64Codec) Decode(buf []byte) (uint64, int) {
+	return binary.LittleEndian.Uint64(buf), 8
+}
+
+// GobCodec is the fallback DocCodec for document id types that aren't
+// uint64 or otherwise fixed-width; it round-trips D through encoding/gob.
+type GobCodec[D any] struct{}
+
+func (GobCodec[D]) Size() int { return 0 }
+
+func (GobCodec[D]) Encode(buf []byte, doc D) []byte {
+	var b bytes.Buffer
+	// encoding/gob only fails on unsupported types, which would already
+	// have failed on the first Encode call; ignore the error here as the
+	// rest of the package does for in-memory operations.
+	_ = gob.NewEncoder(&b).Encode(doc)
+	return append(buf, b.Bytes()...)
+}
+
+func (GobCodec[D]) Decode(buf []byte) (D, int) {
+	var doc D
+	_ = gob.NewDecoder(bytes.NewReader(buf)).Decode(&doc)
+	return doc, len(buf)
+}
+
+// Save writes a finished Store to w so it can be reopened later with
+// LoadStore or Open, skipping a rebuild from the original signatures. The
+// on-disk layout is a small header (magic, version, entry count, k, doc
+// codec size), then the sorted docids table, then each of the rhashes
+// tables prefixed by its entry count.
+func (s *Store[D]) Save(w io.Writer, codec DocCodec[D]) error {
+	bw := bufio.NewWriter(w)
+
+	var header [storeHeaderSize]byte
+	copy(header[0:4], storeMagic)
+	binary.LittleEndian.PutUint32(header[4:8], storeVersion)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(len(s.docids)))
+	binary.LittleEndian.PutUint32(header[16:20], uint32(s.k))
+	binary.LittleEndian.PutUint32(header[20:24], uint32(codec.Size()))
+	if _, err := bw.Write(header[:]); err != nil {
+		return err
+	}
+
+	// Empty store: same convention as Finish/Find. s.rhashes is nil-entried
+	// when New3/New6 was given a zero hashes hint, so there's nothing in it
+	// safe to dump; LoadStore reconstructs an equally empty store from the
+	// header alone, without expecting any rhashes tables to follow.
+	if len(s.docids) == 0 {
+		return bw.Flush()
+	}
+
+	var scratch [8]byte
+	var buf []byte
+	for _, e := range s.docids {
+		binary.LittleEndian.PutUint64(scratch[:], e.hash)
+		if _, err := bw.Write(scratch[:]); err != nil {
+			return err
+		}
+
+		buf = codec.Encode(buf[:0], e.doc)
+		if codec.Size() == 0 {
+			binary.LittleEndian.PutUint64(scratch[:], uint64(len(buf)))
+			if _, err := bw.Write(scratch[:]); err != nil {
+				return err
+			}
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	for _, rh := range s.rhashes {
+		hashes := rh.dump()
+
+		binary.LittleEndian.PutUint64(scratch[:], uint64(len(hashes)))
+		if _, err := bw.Write(scratch[:]); err != nil {
+			return err
+		}
+		for _, h := range hashes {
+			binary.LittleEndian.PutUint64(scratch[:], h)
+			if _, err := bw.Write(scratch[:]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// bytesReaderAt is implemented by an io.ReaderAt backed by a single
+// contiguous in-memory buffer, e.g. a memory-mapped file. LoadStore uses
+// it to view sections of the file directly instead of copying them.
+type bytesReaderAt interface {
+	io.ReaderAt
+	Bytes() []byte
+}
+
+// LoadStore reconstructs a Store previously written with Save. newStore
+// selects the u64store backend for the rhashes tables, exactly as passed
+// to New3/New6. If r exposes its bytes directly (as Open's mmapped file
+// does), LoadStore reads the docids and rhashes tables straight out of
+// that memory rather than through r, viewing them in place where the codec
+// and backend allow it and decoding from it otherwise; see Open.
+func LoadStore[D any](r io.ReaderAt, codec DocCodec[D], newStore func(int) u64store) (*Store[D], error) {
+	var header [storeHeaderSize]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if string(header[0:4]) != storeMagic {
+		return nil, errBadMagic
+	}
+	if binary.LittleEndian.Uint32(header[4:8]) != storeVersion {
+		return nil, errBadVersion
+	}
+
+	n := int(binary.LittleEndian.Uint64(header[8:16]))
+	k := int(binary.LittleEndian.Uint32(header[16:20]))
+	fixedSize := int(binary.LittleEndian.Uint32(header[20:24]))
+
+	if raw, ok := r.(bytesReaderAt); ok {
+		return loadStoreFromBytes[D](raw.Bytes(), n, k, fixedSize, codec, newStore)
+	}
+	return loadStoreSequential[D](r, n, k, fixedSize, codec, newStore)
+}
+
+// nativeLittleEndian reports whether the host's native byte order is
+// little-endian, i.e. whether a []byte written by Save (always
+// little-endian) can be reinterpreted in place as the numbers it encodes.
+var nativeLittleEndian = func() bool {
+	var x uint16 = 1
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// loadStoreFromBytes decodes a Store directly out of buf - the full
+// contents of a Save'd file - viewing data in place rather than copying it
+// where possible: the docids table is a direct []entry[D] view of buf when
+// D is uint64 and codec is the matching Uint64Codec, since that's the only
+// case where the on-disk layout (hash, doc, hash, doc, ...) matches
+// entry[D]'s in-memory layout exactly, and every rhashes table is built
+// via loadSorted from a view of its on-disk hashes rather than the
+// add-then-sort LoadStore used to do.
+func loadStoreFromBytes[D any](buf []byte, n, k, fixedSize int, codec DocCodec[D], newStore func(int) u64store) (*Store[D], error) {
+	s := &Store[D]{k: k}
+
+	off := storeHeaderSize
+	if docids, next, ok := viewDocids[D](buf, off, n, fixedSize, codec); ok {
+		s.docids = docids
+		off = next
+	} else {
+		docids, next, err := decodeDocidsFromBytes[D](buf[off:], n, fixedSize, codec)
+		if err != nil {
+			return nil, err
+		}
+		s.docids = docids
+		off += next
+	}
+
+	numTables := len(configForK(k).Permutations())
+
+	// Empty store: Save wrote no rhashes tables to follow, matching
+	// New3/New6's own nil-entried s.rhashes for a zero hashes hint.
+	if n == 0 {
+		s.rhashes = make([]u64store, numTables)
+		return s, nil
+	}
+
+	s.rhashes = make([]u64store, numTables)
+	for t := 0; t < numTables; t++ {
+		if off+8 > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		tlen := int(binary.LittleEndian.Uint64(buf[off:]))
+		off += 8
+
+		need := tlen * 8
+		if off+need > len(buf) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		rh := newStore(tlen)
+		rh.loadSorted(viewUint64s(buf[off : off+need]))
+		off += need
+
+		s.rhashes[t] = rh
+	}
+
+	return s, nil
+}
+
+// viewUint64s reinterprets buf, which must hold len(buf)/8 little-endian
+// uint64s back to back, as a []uint64 without copying. Falls back to a
+// decoded copy on a big-endian host, or when buf doesn't start at an
+// 8-byte-aligned address, where the bytes Save wrote can't be
+// reinterpreted directly.
+func viewUint64s(buf []byte) []uint64 {
+	if len(buf) == 0 {
+		return nil
+	}
+	if !nativeLittleEndian || uintptr(unsafe.Pointer(&buf[0]))%8 != 0 {
+		hashes := make([]uint64, len(buf)/8)
+		for i := range hashes {
+			hashes[i] = binary.LittleEndian.Uint64(buf[i*8:])
+		}
+		return hashes
+	}
+	return unsafe.Slice((*uint64)(unsafe.Pointer(&buf[0])), len(buf)/8)
+}
+
+// viewDocids views the docids table directly out of buf at off, when D is
+// uint64, codec is the matching fixed-width Uint64Codec, and the host is
+// little-endian - the only case where the on-disk layout matches
+// entry[D]'s in-memory layout exactly. It reports whether the fast path
+// applied, and if so the offset just past the docids section.
+func viewDocids[D any](buf []byte, off, n, fixedSize int, codec DocCodec[D]) (table[D], int, bool) {
+	if fixedSize != 8 || !nativeLittleEndian {
+		return nil, 0, false
+	}
+	if _, ok := any(codec).(Uint64Codec); !ok {
+		return nil, 0, false
+	}
+	var zero D
+	if _, ok := any(zero).(uint64); !ok {
+		return nil, 0, false
+	}
+	if n == 0 {
+		return table[D]{}, off, true
+	}
+
+	need := n * 16
+	if off+need > len(buf) {
+		return nil, 0, false
+	}
+
+	entries := unsafe.Slice((*entry[uint64])(unsafe.Pointer(&buf[off])), n)
+	return *(*table[D])(unsafe.Pointer(&entries)), off + need, true
+}
+
+// decodeDocidsFromBytes decodes the docids table out of buf for codecs
+// that can't be viewed in place (e.g. GobCodec, or any D other than
+// uint64), returning the table and the number of bytes consumed.
+func decodeDocidsFromBytes[D any](buf []byte, n, fixedSize int, codec DocCodec[D]) (table[D], int, error) {
+	docids := make(table[D], n)
+
+	off := 0
+	for i := 0; i < n; i++ {
+		if off+8 > len(buf) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		hash := binary.LittleEndian.Uint64(buf[off:])
+		off += 8
+
+		blobLen := fixedSize
+		if blobLen == 0 {
+			if off+8 > len(buf) {
+				return nil, 0, io.ErrUnexpectedEOF
+			}
+			blobLen = int(binary.LittleEndian.Uint64(buf[off:]))
+			off += 8
+		}
+
+		if off+blobLen > len(buf) {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		doc, _ := codec.Decode(buf[off : off+blobLen])
+		off += blobLen
+
+		docids[i] = entry[D]{hash: hash, doc: doc}
+	}
+
+	return docids, off, nil
+}
+
+// loadStoreSequential decodes a Store by reading r incrementally, for an
+// io.ReaderAt (e.g. a plain *os.File) that doesn't expose a contiguous
+// backing buffer to view in place.
+func loadStoreSequential[D any](r io.ReaderAt, n, k, fixedSize int, codec DocCodec[D], newStore func(int) u64store) (*Store[D], error) {
+	br := bufio.NewReader(io.NewSectionReader(r, storeHeaderSize, 1<<62))
+
+	s := &Store[D]{k: k}
+	s.docids = make(table[D], n)
+
+	var scratch [8]byte
+	var blob []byte
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(br, scratch[:]); err != nil {
+			return nil, err
+		}
+		hash := binary.LittleEndian.Uint64(scratch[:])
+
+		blobLen := fixedSize
+		if blobLen == 0 {
+			if _, err := io.ReadFull(br, scratch[:]); err != nil {
+				return nil, err
+			}
+			blobLen = int(binary.LittleEndian.Uint64(scratch[:]))
+		}
+
+		if cap(blob) < blobLen {
+			blob = make([]byte, blobLen)
+		}
+		blob = blob[:blobLen]
+		if _, err := io.ReadFull(br, blob); err != nil {
+			return nil, err
+		}
+		doc, _ := codec.Decode(blob)
+
+		s.docids[i] = entry[D]{hash: hash, doc: doc}
+	}
+
+	numTables := len(configForK(k).Permutations())
+
+	// Empty store: Save wrote no rhashes tables to follow, matching
+	// New3/New6's own nil-entried s.rhashes for a zero hashes hint.
+	if n == 0 {
+		s.rhashes = make([]u64store, numTables)
+		return s, nil
+	}
+
+	s.rhashes = make([]u64store, numTables)
+	var hb []byte
+	for t := 0; t < numTables; t++ {
+		if _, err := io.ReadFull(br, scratch[:]); err != nil {
+			return nil, err
+		}
+		tlen := int(binary.LittleEndian.Uint64(scratch[:]))
+
+		need := tlen * 8
+		if cap(hb) < need {
+			hb = make([]byte, need)
+		}
+		hb = hb[:need]
+		if _, err := io.ReadFull(br, hb); err != nil {
+			return nil, err
+		}
+
+		hashes := make([]uint64, tlen)
+		for i := range hashes {
+			hashes[i] = binary.LittleEndian.Uint64(hb[i*8:])
+		}
+
+		rh := newStore(tlen)
+		rh.loadSorted(hashes)
+		s.rhashes[t] = rh
+	}
+
+	return s, nil
+}
+
+// mmapFile is a read-only memory-mapped file. Unlike a plain io.ReaderAt
+// over an *os.File, it exposes its bytes directly (Bytes), which is what
+// lets LoadStore view a Store's tables straight out of the mapped region
+// instead of copying them.
+type mmapFile struct {
+	data []byte
+}
+
+func openMmap(path string) (*mmapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		return &mmapFile{}, nil
+	}
+
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapFile{data: data}, nil
+}
+
+func (m *mmapFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Bytes returns the file's full mapped contents.
+func (m *mmapFile) Bytes() []byte { return m.data }
+
+func (m *mmapFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return unix.Munmap(data)
+}
+
+// Open mmaps path and loads the Store it holds, so the docids and rhashes
+// tables are served directly from the mapped region rather than read
+// wholesale into process memory, letting large indices be shared read-only
+// across processes and skip rebuilding them on restart. This is genuinely
+// zero-copy for a Uint64Codec docids table and a u64slice-backed rhashes
+// table: both end up as slices viewing the mapped bytes directly, so
+// callers must keep the returned io.Closer open for as long as they use
+// the Store. Other codecs and backends (GobCodec, u64packed) still skip
+// LoadStore's old add-then-sort/pack rebuild, just not the mapped view
+// itself, since their in-memory representation can't alias the on-disk
+// bytes directly. Callers must Close the returned io.Closer once done with
+// the Store to unmap the file.
+func Open[D any](path string, codec DocCodec[D], newStore func(int) u64store) (*Store[D], io.Closer, error) {
+	mf, err := openMmap(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := LoadStore[D](mf, codec, newStore)
+	if err != nil {
+		mf.Close()
+		return nil, nil, err
+	}
+
+	return s, mf, nil
+}
@@ -0,0 +1,126 @@
+package simstore
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func buildTestStore(rng *rand.Rand, n int, newStore func(int) u64store) *Store[uint64] {
+	s := New3[uint64](n, newStore)
+	for i := 0; i < n; i++ {
+		h := rng.Uint64()
+		s.Add(h, h)
+	}
+	s.Finish()
+	return s
+}
+
+func checkFindEquivalent(t *testing.T, want, got *Store[uint64], queries []uint64) {
+	t.Helper()
+	for _, sig := range queries {
+		w := want.Find(sig)
+		g := got.Find(sig)
+		sort.Slice(w, func(i, j int) bool { return w[i] < w[j] })
+		sort.Slice(g, func(i, j int) bool { return g[i] < g[j] })
+		if len(w) != len(g) {
+			t.Fatalf("sig=%x: want %d results, got %d", sig, len(w), len(g))
+		}
+		for i := range w {
+			if w[i] != g[i] {
+				t.Fatalf("sig=%x: mismatch at %d: want %x got %x", sig, i, w[i], g[i])
+			}
+		}
+	}
+}
+
+// TestSaveLoadStoreBytesReader round-trips a Store through Save/LoadStore
+// over a plain bytes.Reader, the fallback path for an io.ReaderAt that
+// doesn't expose a contiguous backing buffer.
+func TestSaveLoadStoreBytesReader(t *testing.T) {
+	rng := rand.New(rand.NewSource(8))
+	s := buildTestStore(rng, 2000, NewU64Slice)
+
+	var buf bytes.Buffer
+	if err := s.Save(&buf, Uint64Codec{}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadStore[uint64](bytes.NewReader(buf.Bytes()), Uint64Codec{}, NewU64Slice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := make([]uint64, 20)
+	for i := range queries {
+		queries[i] = rng.Uint64()
+	}
+	checkFindEquivalent(t, s, loaded, queries)
+}
+
+// TestSaveLoadEmptyStore checks that Save/LoadStore don't panic on a store
+// built with a zero hashes hint that Add was never called on - s.rhashes is
+// nil-entried in that case, rather than holding an empty backend per table.
+func TestSaveLoadEmptyStore(t *testing.T) {
+	s := New3[uint64](0, NewU64Slice)
+	s.Finish()
+
+	var buf bytes.Buffer
+	if err := s.Save(&buf, Uint64Codec{}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadStore[uint64](bytes.NewReader(buf.Bytes()), Uint64Codec{}, NewU64Slice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Find(12345); len(got) != 0 {
+		t.Fatalf("Find on empty store: got %d results, want 0", len(got))
+	}
+}
+
+// TestSaveOpenMmap round-trips a Store through Save/Open, exercising the
+// mmap-backed zero-copy path (and the non-zero-copy fallback for the
+// u64packed backend) against a real file.
+func TestSaveOpenMmap(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+
+	for _, backend := range []struct {
+		name string
+		new  func(int) u64store
+	}{
+		{"u64slice", NewU64Slice},
+		{"u64packed", NewU64Packed},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			s := buildTestStore(rng, 3000, backend.new)
+
+			path := filepath.Join(t.TempDir(), "store.bin")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(f, Uint64Codec{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, closer, err := Open[uint64](path, Uint64Codec{}, backend.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closer.Close()
+
+			queries := make([]uint64, 20)
+			for i := range queries {
+				queries[i] = rng.Uint64()
+			}
+			checkFindEquivalent(t, s, loaded, queries)
+		})
+	}
+}
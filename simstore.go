@@ -6,11 +6,15 @@ for Web Crawling" by Manku, Jain, and Sarma,
 
     http://www2007.org/papers/paper215.pdf
 
-It is hard-coded for hamming distance 3 or 6.
+New3 and New6 build a Store for hamming distance 3 or 6 respectively; both
+are driven by a declarative Config (see config.go) describing how the
+signature is split into blocks and how many of them are needed as a table
+prefix to guarantee coverage of that distance.
 */
 package simstore
 
 import (
+	"context"
 	"runtime"
 	"sort"
 	"sync"
@@ -29,8 +33,6 @@ func (t table[D]) Len() int           { return len(t) }
 func (t table[D]) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
 func (t table[D]) Less(i, j int) bool { return t[i].hash < t[j].hash }
 
-const mask3 = 0xfffffff000000000
-
 func (t table[D]) find(sig uint64) []D {
 
 	i := sort.Search(len(t), func(i int) bool { return t[i].hash >= sig })
@@ -54,6 +56,17 @@ type u64store interface {
 	add(hash uint64)
 	find(sig uint64, mask uint64, d int) []uint64
 	finish()
+
+	// dump returns every hash currently in the store, in ascending sorted
+	// order. Only valid after finish().
+	dump() []uint64
+
+	// loadSorted replaces the store's contents with hashes, which must
+	// already be sorted ascending. It's equivalent to calling add for each
+	// hash followed by finish, but skips redoing work Save already
+	// captured on disk (the sort, and for u64slice the copy); used by
+	// LoadStore.
+	loadSorted(hashes []uint64)
 }
 
 // a store for uint64s
@@ -88,16 +101,27 @@ func (u u64slice) finish() {
 	sort.Sort(u)
 }
 
+func (u u64slice) dump() []uint64 {
+	return u
+}
+
+func (u *u64slice) loadSorted(hashes []uint64) {
+	*u = hashes
+}
+
 // Store is a storage engine for 64-bit hashes
 type Store[D any] struct {
 	docids  table[D]
 	rhashes []u64store
+
+	// k is the hamming distance bound this store was built for, 3 or 6.
+	k int
 }
 
 // New3 returns a Store for searching hamming distance <= 3
 func New3[D any](hashes int, newStore func(int) u64store) *Store[D] {
-	s := Store[D]{}
-	s.rhashes = make([]u64store, 16)
+	s := Store[D]{k: 3}
+	s.rhashes = make([]u64store, len(cfg3.Permutations()))
 	if hashes != 0 {
 		s.docids = make(table[D], 0, hashes)
 		for i := range s.rhashes {
@@ -110,52 +134,14 @@ func New3[D any](hashes int, newStore func(int) u64store) *Store[D] {
 // Add inserts a signature and document id into the store
 func (s *Store[D]) Add(sig uint64, doc D) {
 
-	var t int
-
 	s.docids = append(s.docids, entry[D]{hash: sig, doc: doc})
 
-	for i := 0; i < 4; i++ {
-		p := sig
-		s.rhashes[t].add(p)
-		t++
-
-		p = (sig & 0xffff000000ffffff) | (sig & 0x0000fff000000000 >> 12) | (sig & 0x0000000fff000000 << 12)
-		s.rhashes[t].add(p)
-		t++
-
-		p = (sig & 0xffff000fff000fff) | (sig & 0x0000fff000000000 >> 24) | (sig & 0x0000000000fff000 << 24)
-		s.rhashes[t].add(p)
-		t++
-
-		p = (sig & 0xffff000ffffff000) | (sig & 0x0000fff000000000 >> 36) | (sig & 0x0000000000000fff << 36)
-		s.rhashes[t].add(p)
-		t++
-
-		sig = (sig << 16) | (sig >> (64 - 16))
+	cfg := s.config()
+	for t, perm := range cfg.Permutations() {
+		s.rhashes[t].add(cfg.Shuffle(sig, perm.Blocks))
 	}
 }
 
-func (*Store[D]) unshuffle(sig uint64, t int) uint64 {
-	const m2 = 0x0000fff000000000
-
-	t4 := t % 4
-	shift := 12 * uint64(t4)
-	m3 := uint64(m2 >> shift)
-	m1 := ^uint64(0) &^ (m2 | m3)
-
-	sig = (sig & m1) | (sig & m2 >> shift) | (sig & m3 << shift)
-	sig = (sig >> (16 * (uint64(t) / 4))) | (sig << (64 - (16 * (uint64(t) / 4))))
-	return sig
-}
-
-func (s *Store[D]) unshuffleList(sigs []uint64, t int) []uint64 {
-	for i := range sigs {
-		sigs[i] = s.unshuffle(sigs[i], t)
-	}
-
-	return sigs
-}
-
 type limiter chan struct{}
 
 func (l limiter) enter() { l <- struct{}{} }
@@ -188,37 +174,75 @@ func (s *Store[D]) Finish() {
 	wg.Wait()
 }
 
-// Find searches the store for all hashes hamming distance 3 or less from the
-// query signature.  It returns the associated list of document ids.
-func (s *Store[D]) Find(sig uint64) []D {
+// permQuery is one of the table lookups Find must perform: the shuffled
+// query to look up in rhashes[t], plus the permutation (and its prefix
+// mask, which can vary by permutation - see Config.Permutations) needed to
+// interpret and unshuffle the results it returns.
+type permQuery struct {
+	t    int
+	p    uint64
+	perm Perm
+}
 
-	// empty store
-	if len(s.docids) == 0 {
-		return nil
+// queries builds the list of per-table lookups Find needs to perform for
+// sig, mirroring the shuffles Add applies when inserting a signature.
+func (s *Store[D]) queries(sig uint64) []permQuery {
+	cfg := s.config()
+	perms := cfg.Permutations()
+
+	qs := make([]permQuery, len(perms))
+	for t, perm := range perms {
+		qs[t] = permQuery{t: t, p: cfg.Shuffle(sig, perm.Blocks), perm: perm}
 	}
+	return qs
+}
 
-	var ids []uint64
+// lookup runs a single table lookup and returns the matching hashes
+// unshuffled back into the original signature space.
+func (s *Store[D]) lookup(q permQuery) []uint64 {
+	cfg := s.config()
 
-	// TODO(dgryski): search in parallel
-	var t int
-	for i := 0; i < 4; i++ {
-		p := sig
-		ids = append(ids, s.unshuffleList(s.rhashes[t].find(p, mask3, 3), t)...)
-		t++
+	found := s.rhashes[q.t].find(q.p, q.perm.Mask, s.k)
+	for i := range found {
+		found[i] = cfg.Unshuffle(found[i], q.perm.Blocks)
+	}
+	return found
+}
 
-		p = (sig & 0xffff000000ffffff) | (sig & 0x0000fff000000000 >> 12) | (sig & 0x0000000fff000000 << 12)
-		ids = append(ids, s.unshuffleList(s.rhashes[t].find(p, mask3, 3), t)...)
-		t++
+// findParallelThreshold is the minimum number of stored signatures at which
+// Find fans its table lookups out across a worker pool; below it the
+// goroutine dispatch overhead outweighs the benefit of running the
+// store's permutations (see Config.Permutations) concurrently.
+const findParallelThreshold = 1 << 14
 
-		p = (sig & 0xffff000fff000fff) | (sig & 0x0000fff000000000 >> 24) | (sig & 0x0000000000fff000 << 24)
-		ids = append(ids, s.unshuffleList(s.rhashes[t].find(p, mask3, 3), t)...)
-		t++
+// Find searches the store for all hashes within the store's hamming distance
+// bound (3 for a New3 store, 6 for a New6 store) of the query signature.  It
+// returns the associated list of document ids.
+func (s *Store[D]) Find(sig uint64) []D {
+	return s.FindContext(context.Background(), sig)
+}
 
-		p = (sig & 0xffff000ffffff000) | (sig & 0x0000fff000000000 >> 36) | (sig & 0x0000000000000fff << 36)
-		ids = append(ids, s.unshuffleList(s.rhashes[t].find(p, mask3, 3), t)...)
-		t++
+// FindContext is like Find but allows the caller to cancel a lookup that is
+// taking too long, e.g. on a large store under load.
+func (s *Store[D]) FindContext(ctx context.Context, sig uint64) []D {
 
-		sig = (sig << 16) | (sig >> (64 - 16))
+	// empty store
+	if len(s.docids) == 0 {
+		return nil
+	}
+
+	queries := s.queries(sig)
+
+	var ids []uint64
+	if len(s.docids) < findParallelThreshold {
+		for _, q := range queries {
+			if ctx.Err() != nil {
+				break
+			}
+			ids = append(ids, s.lookup(q)...)
+		}
+	} else {
+		ids = s.findParallel(ctx, queries)
 	}
 
 	ids = unique(ids)
@@ -231,6 +255,66 @@ func (s *Store[D]) Find(sig uint64) []D {
 	return docs
 }
 
+// findParallel runs queries across a bounded pool of worker goroutines,
+// sized to GOMAXPROCS like the limiter Finish uses, and merges the
+// per-table results. Since a store's permutations are independent of each
+// other, this gives near-linear speedup on multi-core lookups.
+//
+// Workers pull from a shared queue instead of each query getting its own
+// limiter-gated goroutine: a store only has a handful to a few dozen
+// tables, so on a typical multi-core machine the old per-query goroutines
+// were all dispatched (and usually finished) before ctx ever had a chance
+// to be observed cancelled, making FindContext's cancellation a no-op in
+// the common case. Feeding the queue lets a cancelled ctx stop queries
+// that haven't started yet; a lookup already in flight still runs to
+// completion, since a single table scan isn't itself interruptible.
+func (s *Store[D]) findParallel(ctx context.Context, queries []permQuery) []uint64 {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+
+	work := make(chan permQuery)
+	go func() {
+		defer close(work)
+		for _, q := range queries {
+			select {
+			case work <- q:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var ids []uint64
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for q := range work {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				found := s.lookup(q)
+				if len(found) == 0 {
+					continue
+				}
+
+				mu.Lock()
+				ids = append(ids, found...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ids
+}
+
 // SmallStore3 is a simstore for distance k=3 with smaller memory requirements
 type SmallStore3[D comparable] struct {
 	tables [4][1 << 16]table[D]
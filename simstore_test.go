@@ -0,0 +1,161 @@
+package simstore
+
+import (
+	"context"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// bruteForceFind returns the docs among hashes within hamming distance d of
+// sig, by checking every one - the reference implementation Store.Find is
+// checked against.
+func bruteForceFind(hashes []uint64, sig uint64, d int) map[uint64]bool {
+	found := make(map[uint64]bool)
+	for _, h := range hashes {
+		if bits.OnesCount64(h^sig) <= d {
+			found[h] = true
+		}
+	}
+	return found
+}
+
+func resultSet(docs []uint64) map[uint64]bool {
+	set := make(map[uint64]bool, len(docs))
+	for _, d := range docs {
+		set[d] = true
+	}
+	return set
+}
+
+func TestNew3FindMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+
+	var hashes []uint64
+	for i := 0; i < 2000; i++ {
+		hashes = append(hashes, rng.Uint64())
+	}
+
+	s := New3[uint64](len(hashes), NewU64Slice)
+	for _, h := range hashes {
+		s.Add(h, h)
+	}
+	s.Finish()
+
+	for trial := 0; trial < 50; trial++ {
+		// query near an existing hash so some distance-<=3 matches are
+		// likely, as well as a handful of fully random queries
+		var sig uint64
+		if trial%2 == 0 {
+			sig = hashes[rng.Intn(len(hashes))] ^ (1 << uint(rng.Intn(64)))
+		} else {
+			sig = rng.Uint64()
+		}
+
+		want := bruteForceFind(hashes, sig, 3)
+		got := resultSet(s.Find(sig))
+		if len(want) != len(got) {
+			t.Fatalf("sig=%x: want %d matches, got %d", sig, len(want), len(got))
+		}
+		for h := range want {
+			if !got[h] {
+				t.Fatalf("sig=%x: missing expected match %x", sig, h)
+			}
+		}
+	}
+}
+
+func TestNew6FindMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+
+	var hashes []uint64
+	for i := 0; i < 2000; i++ {
+		hashes = append(hashes, rng.Uint64())
+	}
+
+	s := New6[uint64](len(hashes), NewU64Slice)
+	for _, h := range hashes {
+		s.Add(h, h)
+	}
+	s.Finish()
+
+	for trial := 0; trial < 50; trial++ {
+		var sig uint64
+		if trial%2 == 0 {
+			sig = hashes[rng.Intn(len(hashes))] ^ (1 << uint(rng.Intn(64)))
+		} else {
+			sig = rng.Uint64()
+		}
+
+		want := bruteForceFind(hashes, sig, 6)
+		got := resultSet(s.Find(sig))
+		if len(want) != len(got) {
+			t.Fatalf("sig=%x: want %d matches, got %d", sig, len(want), len(got))
+		}
+		for h := range want {
+			if !got[h] {
+				t.Fatalf("sig=%x: missing expected match %x", sig, h)
+			}
+		}
+	}
+}
+
+// TestFindParallelMatchesSerial checks that findParallel's fanned-out
+// table lookups return the same results as running them serially.
+func TestFindParallelMatchesSerial(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	const n = findParallelThreshold + 1000
+	s := New3[uint64](n, NewU64Slice)
+	for i := 0; i < n; i++ {
+		h := rng.Uint64()
+		s.Add(h, h)
+	}
+	s.Finish()
+
+	for trial := 0; trial < 20; trial++ {
+		sig := rng.Uint64()
+		queries := s.queries(sig)
+
+		var serial []uint64
+		for _, q := range queries {
+			serial = append(serial, s.lookup(q)...)
+		}
+		serial = unique(serial)
+
+		parallel := unique(s.findParallel(context.Background(), queries))
+
+		wantSet := resultSet(serial)
+		gotSet := resultSet(parallel)
+		if len(wantSet) != len(gotSet) {
+			t.Fatalf("sig=%x: serial found %d, parallel found %d", sig, len(wantSet), len(gotSet))
+		}
+		for h := range wantSet {
+			if !gotSet[h] {
+				t.Fatalf("sig=%x: parallel missing serial match %x", sig, h)
+			}
+		}
+	}
+}
+
+// TestFindContextCancelled checks that an already-cancelled context
+// doesn't hang or panic FindContext on either the serial or parallel path.
+func TestFindContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	small := New3[uint64](10, NewU64Slice)
+	for i := 0; i < 10; i++ {
+		small.Add(uint64(i), uint64(i))
+	}
+	small.Finish()
+	_ = small.FindContext(ctx, 12345)
+
+	const n = findParallelThreshold + 10
+	big := New3[uint64](n, NewU64Slice)
+	for i := 0; i < n; i++ {
+		big.Add(uint64(i), uint64(i))
+	}
+	big.Finish()
+	_ = big.FindContext(ctx, 12345)
+}
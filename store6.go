@@ -0,0 +1,64 @@
+package simstore
+
+import "sort"
+
+// New6 returns a Store for searching hamming distance <= 6
+func New6[D any](hashes int, newStore func(int) u64store) *Store[D] {
+	s := Store[D]{k: 6}
+	s.rhashes = make([]u64store, len(cfg6.Permutations()))
+	if hashes != 0 {
+		s.docids = make(table[D], 0, hashes)
+		for i := range s.rhashes {
+			s.rhashes[i] = newStore(hashes)
+		}
+	}
+	return &s
+}
+
+// smallStore6Tables is len(cfg6.Permutations()), i.e. C(8, 2): the 8-block,
+// 2-block-prefix config declared in config.go fixes it at 28.
+const smallStore6Tables = 28
+
+// SmallStore6 is a simstore for distance k=6 laid out like SmallStore3: each
+// table buckets full entries by a 16-bit prefix. It keeps one table per
+// cfg6 permutation (28), each holding an entry for every signature, so it
+// uses more memory per signature than a New6 Store.
+type SmallStore6[D comparable] struct {
+	tables [smallStore6Tables][1 << 16]table[D]
+}
+
+func New6Small[D comparable](hashes int) *SmallStore6[D] {
+	return &SmallStore6[D]{}
+}
+
+func (s *SmallStore6[D]) Add(sig uint64, doc D) {
+	for t, perm := range cfg6.Permutations() {
+		p := cfg6.Shuffle(sig, perm.Blocks)
+		prefix := p >> uint(64-perm.Bits)
+		s.tables[t][prefix] = append(s.tables[t][prefix], entry[D]{hash: sig, doc: doc})
+	}
+}
+
+func (s *SmallStore6[D]) Find(sig uint64) []D {
+	var docs []D
+	for t, perm := range cfg6.Permutations() {
+		p := cfg6.Shuffle(sig, perm.Blocks)
+		prefix := p >> uint(64-perm.Bits)
+
+		tbl := s.tables[t][prefix]
+		for i := range tbl {
+			if distance(tbl[i].hash, sig) <= 6 {
+				docs = append(docs, tbl[i].doc)
+			}
+		}
+	}
+	return unique(docs)
+}
+
+func (s *SmallStore6[D]) Finish() {
+	for i := range s.tables {
+		for p := range s.tables[i] {
+			sort.Sort(s.tables[i][p])
+		}
+	}
+}
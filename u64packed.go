@@ -0,0 +1,149 @@
+package simstore
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// packedBlockSize is the number of hashes grouped into each delta-compressed
+// block of a u64packed store.
+const packedBlockSize = 128
+
+// packedBlock is the per-block index entry for a u64packed store: the
+// block's first (smallest) hash and the byte offset in data where its
+// remaining, delta-encoded hashes begin.
+type packedBlock struct {
+	first  uint64
+	offset int
+	count  int
+}
+
+// u64packed is a u64store that keeps its sorted hashes as delta-compressed
+// blocks instead of a flat slice. After Finish() the prefix bits shared by
+// every hash in a table vary little within a block, so the gaps between
+// consecutive hashes are typically small and pack into 1-2 bytes each,
+// cutting memory use substantially for large corpora at the cost of a
+// linear decode within the matching block.
+type u64packed struct {
+	raw   []uint64
+	index []packedBlock
+	data  []byte
+}
+
+// NewU64Packed returns a u64store backed by delta-compressed blocks.
+func NewU64Packed(hashes int) u64store {
+	u := &u64packed{raw: make([]uint64, 0, hashes)}
+	return u
+}
+
+func (u *u64packed) add(hash uint64) {
+	u.raw = append(u.raw, hash)
+}
+
+func (u *u64packed) finish() {
+	sort.Slice(u.raw, func(i, j int) bool { return u.raw[i] < u.raw[j] })
+	u.pack()
+}
+
+// loadSorted is finish without the sort, for hashes that are already
+// known to be sorted ascending (e.g. read back from a Save'd file).
+func (u *u64packed) loadSorted(hashes []uint64) {
+	u.raw = hashes
+	u.pack()
+}
+
+// pack builds the block index and delta-encoded data from u.raw, which
+// must already be sorted ascending.
+func (u *u64packed) pack() {
+	var buf [binary.MaxVarintLen64]byte
+	for start := 0; start < len(u.raw); start += packedBlockSize {
+		end := start + packedBlockSize
+		if end > len(u.raw) {
+			end = len(u.raw)
+		}
+
+		block := packedBlock{first: u.raw[start], offset: len(u.data), count: end - start}
+
+		prev := block.first
+		for i := start + 1; i < end; i++ {
+			n := binary.PutUvarint(buf[:], u.raw[i]-prev)
+			u.data = append(u.data, buf[:n]...)
+			prev = u.raw[i]
+		}
+
+		u.index = append(u.index, block)
+	}
+
+	u.raw = nil
+}
+
+func (u *u64packed) dump() []uint64 {
+	hashes := make([]uint64, 0, len(u.index)*packedBlockSize)
+
+	for _, block := range u.index {
+		hash := block.first
+		pos := block.offset
+
+		for i := 0; i < block.count; i++ {
+			if i > 0 {
+				gap, n := binary.Uvarint(u.data[pos:])
+				pos += n
+				hash += gap
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes
+}
+
+func (u *u64packed) find(sig, mask uint64, d int) []uint64 {
+	if len(u.index) == 0 {
+		return nil
+	}
+
+	prefix := sig & mask
+
+	// Find the first block whose own first hash is already >= prefix, then
+	// back up one more: a block's entries are all <= the next block's first
+	// hash, so only the block immediately before this one can still have
+	// trailing entries == prefix despite a first hash < prefix. Any block
+	// before that necessarily has every entry < prefix too. Searching for
+	// "> prefix" instead and backing up one block is not enough when a run
+	// of identical hashes spans more than one block: every block in that
+	// run also has first == prefix, so ">" skips past all but the last of
+	// them instead of landing on the first.
+	bi := sort.Search(len(u.index), func(i int) bool { return u.index[i].first >= prefix })
+	if bi > 0 {
+		bi--
+	}
+
+	var ids []uint64
+
+	for ; bi < len(u.index); bi++ {
+		block := u.index[bi]
+		hash := block.first
+		pos := block.offset
+
+		for i := 0; i < block.count; i++ {
+			if i > 0 {
+				gap, n := binary.Uvarint(u.data[pos:])
+				pos += n
+				hash += gap
+			}
+
+			if hash&mask != prefix {
+				if hash&mask > prefix {
+					return ids
+				}
+				continue
+			}
+
+			if distance(hash, sig) <= d {
+				ids = append(ids, hash)
+			}
+		}
+	}
+
+	return ids
+}
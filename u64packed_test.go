@@ -0,0 +1,70 @@
+package simstore
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestU64PackedDuplicateRun guards against a run of identical hashes
+// spilling across more than one packedBlockSize block: find used to back
+// up only a single block from the boundary it found, silently dropping
+// every earlier block in such a run.
+func TestU64PackedDuplicateRun(t *testing.T) {
+	u := NewU64Packed(0).(*u64packed)
+	const n = 3 * packedBlockSize
+	for i := 0; i < n; i++ {
+		u.add(0xdeadbeef)
+	}
+	u.finish()
+
+	got := u.find(0xdeadbeef, ^uint64(0), 0)
+	if len(got) != n {
+		t.Fatalf("got %d results, want %d", len(got), n)
+	}
+}
+
+// TestU64PackedVsSlice cross-checks u64packed against u64slice (the
+// reference, uncompressed backend) on the same random hash set, including
+// a forced run of identical hashes long enough to span several blocks.
+func TestU64PackedVsSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	var hashes []uint64
+	for i := 0; i < 5000; i++ {
+		hashes = append(hashes, rng.Uint64())
+	}
+	for i := 0; i < 3*packedBlockSize; i++ {
+		hashes = append(hashes, 0xcafebabecafebabe)
+	}
+
+	packed := NewU64Packed(0).(*u64packed)
+	var flat u64slice
+	for _, h := range hashes {
+		packed.add(h)
+		flat = append(flat, h)
+	}
+	packed.finish()
+	flat.finish()
+
+	const mask = uint64(0xffff000000000000)
+	for trial := 0; trial < 500; trial++ {
+		sig := hashes[rng.Intn(len(hashes))]
+		d := rng.Intn(8)
+
+		want := flat.find(sig, mask, d)
+		got := packed.find(sig, mask, d)
+
+		sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+
+		if len(want) != len(got) {
+			t.Fatalf("sig=%x d=%d: want %d results, got %d", sig, d, len(want), len(got))
+		}
+		for i := range want {
+			if want[i] != got[i] {
+				t.Fatalf("sig=%x d=%d: mismatch at %d: want %x got %x", sig, d, i, want[i], got[i])
+			}
+		}
+	}
+}